@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProviderConfig describes how to reach one backend: its base URL, which
+// environment variable holds the API key, and the model name to request.
+type ProviderConfig struct {
+	BaseURL string `yaml:"base_url"`
+	KeyEnv  string `yaml:"key_env"`
+	Model   string `yaml:"model"`
+}
+
+// Config is the schema for ~/.config/herochat/config.yaml.
+type Config struct {
+	DefaultProvider string                    `yaml:"default_provider"`
+	Providers       map[string]ProviderConfig `yaml:"providers"`
+}
+
+// defaultConfig is used when no config file exists, so the tool keeps
+// working out of the box against Heroku's managed inference.
+func defaultConfig() Config {
+	return Config{
+		DefaultProvider: "heroku",
+		Providers: map[string]ProviderConfig{
+			"heroku": {
+				BaseURL: "https://eu.inference.heroku.com",
+				KeyEnv:  "INFERENCE_KEY",
+				Model:   "claude-4-sonnet",
+			},
+			"openai": {
+				BaseURL: "https://api.openai.com",
+				KeyEnv:  "OPENAI_API_KEY",
+				Model:   "gpt-4o",
+			},
+			"anthropic": {
+				BaseURL: "https://api.anthropic.com",
+				KeyEnv:  "ANTHROPIC_API_KEY",
+				Model:   "claude-sonnet-4-5",
+			},
+			"ollama": {
+				BaseURL: "http://localhost:11434",
+				KeyEnv:  "",
+				Model:   "llama3",
+			},
+			"gemini": {
+				BaseURL: "https://generativelanguage.googleapis.com",
+				KeyEnv:  "GEMINI_API_KEY",
+				Model:   "gemini-1.5-pro",
+			},
+		},
+	}
+}
+
+// configPath returns ~/.config/herochat/config.yaml.
+func configPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve home directory: %v", err)
+	}
+	return filepath.Join(home, ".config", "herochat", "config.yaml"), nil
+}
+
+// loadConfig reads the user config file, falling back to defaultConfig when
+// it does not exist. Providers omitted from the file fall back to their
+// built-in defaults individually.
+func loadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	path, err := configPath()
+	if err != nil {
+		return cfg, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	var fileCfg Config
+	if err := yaml.Unmarshal(data, &fileCfg); err != nil {
+		return cfg, fmt.Errorf("failed to parse config: %v", err)
+	}
+	if fileCfg.DefaultProvider != "" {
+		cfg.DefaultProvider = fileCfg.DefaultProvider
+	}
+	for name, pc := range fileCfg.Providers {
+		cfg.Providers[name] = pc
+	}
+	return cfg, nil
+}
+
+// resolveProviderName picks the provider to use: the --provider flag wins,
+// then HEROCHAT_PROVIDER, then the config's default.
+func resolveProviderName(flag string, cfg Config) string {
+	if flag != "" {
+		return flag
+	}
+	if env := os.Getenv("HEROCHAT_PROVIDER"); env != "" {
+		return env
+	}
+	return cfg.DefaultProvider
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+// Node represents a single turn in the conversation tree. Conversations are
+// not a flat list: every node points at the parent it branched from, so a
+// past turn can be edited and re-submitted without losing the original
+// continuation. Nodes are persisted one at a time through the active
+// Backend (see store.go).
+type Node struct {
+	ID        string `json:"id"`
+	ParentID  string `json:"parent_id,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+	Timestamp string `json:"timestamp"`
+}
+
+// store is the process-wide Backend handle, opened once in main().
+var store Backend
+
+// newNodeID returns a short random hex ID, good enough to key a local
+// conversation tree without pulling in a UUID dependency.
+func newNodeID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return fmt.Sprintf("%x", b)
+}
+
+// appendNode persists a new node under store and returns its ID so callers
+// can branch off it later.
+func appendNode(parentID, tag, role, content string) (string, error) {
+	return appendNodeWithMeta(parentID, tag, role, content, "", "")
+}
+
+// appendNodeWithMeta is appendNode plus the provider/model that produced an
+// assistant turn, so branches can be resumed against the same backend.
+func appendNodeWithMeta(parentID, tag, role, content, provider, model string) (string, error) {
+	node := Node{
+		ID:        newNodeID(),
+		ParentID:  parentID,
+		Tag:       tag,
+		Role:      role,
+		Content:   content,
+		Provider:  provider,
+		Model:     model,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	if err := store.AppendNode(context.Background(), node); err != nil {
+		return "", err
+	}
+	return node.ID, nil
+}
+
+// childrenOf returns the direct children of a node, in insertion order.
+func childrenOf(nodes []Node, parentID string) []Node {
+	var children []Node
+	for _, n := range nodes {
+		if n.ParentID == parentID {
+			children = append(children, n)
+		}
+	}
+	return children
+}
+
+// leafPath walks from a tag's tip node back to the root, returning the
+// ordered path of messages for that branch (oldest first).
+func leafPath(nodes []Node, leafID string) []Node {
+	byID := map[string]Node{}
+	for _, n := range nodes {
+		byID[n.ID] = n
+	}
+	var path []Node
+	for id := leafID; id != ""; {
+		n, ok := byID[id]
+		if !ok {
+			break
+		}
+		path = append([]Node{n}, path...)
+		id = n.ParentID
+	}
+	return path
+}
+
+// tagTip returns the most recently appended node for a tag, i.e. the tip
+// of its default branch.
+func tagTip(nodes []Node, tag string) string {
+	var tip string
+	for _, n := range nodes {
+		if n.Tag == tag {
+			tip = n.ID
+		}
+	}
+	return tip
+}
+
+// flattenConversations pairs up each user turn on a tag's default branch
+// with the final (non-tool-call) assistant answer that follows it, for the
+// flat Conversation shape that `history`/`navigate` and the message builder
+// expect. Anything in between (tool-call requests and their results) is
+// kept as a ToolTrace for display; toolCalls supplies the name/arguments
+// recorded for each "tool" role node, keyed by that node's ID, so the trace
+// can show which tool ran rather than just its bare result.
+func flattenConversations(nodes []Node, tag string, toolCalls map[string]ToolCallRecord) []Conversation {
+	var flat []Conversation
+	path := leafPath(nodes, tagTip(nodes, tag))
+	i := 0
+	for i < len(path) {
+		if path[i].Role != "user" {
+			i++
+			continue
+		}
+		user := path[i]
+		var trace []string
+		j := i + 1
+		for ; j < len(path); j++ {
+			n := path[j]
+			if n.Role == "assistant" && n.Provider != "" {
+				break // the final, non-tool-call answer to this turn
+			}
+			if n.Role == "tool" {
+				if call, ok := toolCalls[n.ID]; ok {
+					trace = append(trace, fmt.Sprintf("[tool:%s(%s)] %s", call.ToolName, call.Arguments, n.Content))
+					continue
+				}
+			}
+			trace = append(trace, fmt.Sprintf("[%s] %s", n.Role, n.Content))
+		}
+		if j >= len(path) {
+			break // turn has no final answer yet (mid tool-loop, or truncated)
+		}
+		assistant := path[j]
+		flat = append(flat, Conversation{
+			Prompt:    user.Content,
+			Response:  assistant.Content,
+			Timestamp: assistant.Timestamp,
+			Tag:       tag,
+			Provider:  assistant.Provider,
+			Model:     assistant.Model,
+			ToolTrace: trace,
+		})
+		i = j + 1
+	}
+	return flat
+}
@@ -2,38 +2,69 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
-// Conversation represents a chat interaction
+// Conversation represents a chat interaction. It is the flattened view of
+// the default branch of a tag's Node tree, kept around for `history`,
+// `navigate` and the message builder below.
 type Conversation struct {
-	ID        int       `json:"conversation_id"`
-	Prompt    string    `json:"prompt"`
-	Response  string    `json:"response"`
-	Timestamp string    `json:"timestamp"`
-	Tag       string    `json:"tag,omitempty"` // Optional tag field
+	ID        int      `json:"conversation_id"`
+	Prompt    string   `json:"prompt"`
+	Response  string   `json:"response"`
+	Timestamp string   `json:"timestamp"`
+	Tag       string   `json:"tag,omitempty"` // Optional tag field
+	Provider  string   `json:"provider,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	ToolTrace []string `json:"tool_trace,omitempty"` // tool calls/results between prompt and response, if any
 }
 
 // Message defines the structure for API messages
 type Message struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string     `json:"tool_call_id,omitempty"`
+	// ToolName and ToolArguments are set on "tool" role messages so the
+	// result can be persisted in the tool_calls table alongside the
+	// conversation node, not just flattened into its content.
+	ToolName      string `json:"tool_name,omitempty"`
+	ToolArguments string `json:"tool_arguments,omitempty"`
 }
 
-// Tool defines an MCP tool
+// ToolFunction is the JSON-schema description of a tool sent to the model.
+type ToolFunction struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// Tool defines a tool the model may call.
 type Tool struct {
-	Type string `json:"type"`
-	Name string `json:"name"`
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolCall is a single invocation the model asks the client to run.
+type ToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 // RequestPayload is the structure for Heroku API requests
@@ -46,7 +77,8 @@ type RequestPayload struct {
 // Choice contains the model's response
 type Choice struct {
 	Message struct {
-		Content string `json:"content"`
+		Content   string     `json:"content"`
+		ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	} `json:"message"`
 	FinishReason string `json:"finish_reason"`
 }
@@ -56,174 +88,206 @@ type ResponseData struct {
 	Choices []Choice `json:"choices"`
 }
 
-// loadHistory reads the conversation history from conversations.json
-func loadHistory() ([]Conversation, error) {
-	if _, err := os.Stat("conversations.json"); os.IsNotExist(err) {
-		return []Conversation{}, nil
-	}
-	data, err := os.ReadFile("conversations.json")
-	if err != nil {
-		return nil, err
-	}
-	var history []Conversation
-	if err := json.Unmarshal(data, &history); err != nil {
-		return nil, err
+// scriptResult is the `--format json` shape for the root command, meant
+// for scripting and CI rather than interactive use.
+type scriptResult struct {
+	Prompt    string     `json:"prompt"`
+	Response  string     `json:"response"`
+	ToolCalls []ToolCall `json:"tool_calls"`
+	Tokens    int        `json:"tokens"`
+}
+
+// estimateTokens gives a rough token count for text. None of the
+// providers report real usage figures through this codebase's
+// OpenAI-compatible ResponseData shape, so --format json falls back to
+// the common ~4-characters-per-token approximation rather than an exact
+// count.
+func estimateTokens(s string) int {
+	if s == "" {
+		return 0
 	}
-	return history, nil
+	return (len(s) + 3) / 4
 }
 
-// saveConversation saves a new conversation to conversations.json
-func saveConversation(prompt, response, tag string) error {
-	history, err := loadHistory()
+// isStdinPiped reports whether stdin is redirected from a file or pipe
+// rather than attached to a terminal, so the root command can read a
+// prompt from it without an explicit --stdin flag.
+func isStdinPiped() bool {
+	info, err := os.Stdin.Stat()
 	if err != nil {
-		return err
+		return false
 	}
-	history = append(history, Conversation{
-		ID:        len(history) + 1,
-		Prompt:    prompt,
-		Response:  response,
-		Timestamp: time.Now().Format(time.RFC3339),
-		Tag:       tag,
-	})
-	data, err := json.MarshalIndent(history, "", "  ")
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// readStdin reads all of stdin, trimmed, for use as (or as a suffix to)
+// the prompt.
+func readStdin() (string, error) {
+	data, err := io.ReadAll(os.Stdin)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("failed to read stdin: %v", err)
 	}
-	return os.WriteFile("conversations.json", data, 0644)
+	return strings.TrimSpace(string(data)), nil
 }
 
-// callHeroku sends a prompt to the Heroku API
-func callHeroku(prompt, tag string) (string, error) {
-	inferenceURL := os.Getenv("INFERENCE_URL")
-	if inferenceURL == "" {
-		inferenceURL = "https://eu.inference.heroku.com"
+// loadHistory reads the conversation history from the store, flattened
+// from the underlying Node tree's default branch for every tag (or just
+// one, if tag is non-empty).
+func loadHistory(tag string) ([]Conversation, error) {
+	var nodes []Node
+	var err error
+	if tag != "" {
+		nodes, err = store.NodesByTag(context.Background(), tag)
+	} else {
+		nodes, err = store.AllNodes(context.Background())
 	}
-	inferenceKey := os.Getenv("INFERENCE_KEY")
-	if inferenceKey == "" {
-		return "", fmt.Errorf("INFERENCE_KEY not configured")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load history: %v", err)
 	}
 
-	// Load history for the given tag
-	history, err := loadHistory()
+	var toolNodeIDs []string
+	for _, n := range nodes {
+		if n.Role == "tool" {
+			toolNodeIDs = append(toolNodeIDs, n.ID)
+		}
+	}
+	toolCalls, err := store.ToolCallsByConversationIDs(context.Background(), toolNodeIDs)
 	if err != nil {
-		return "", fmt.Errorf("failed to load history: %v", err)
+		return nil, fmt.Errorf("failed to load tool calls: %v", err)
 	}
-	var messages []Message
+
+	var flat []Conversation
 	if tag != "" {
-		for _, conv := range history {
-			if conv.Tag == tag {
-				messages = append(messages,
-					Message{Role: "user", Content: conv.Prompt},
-					Message{Role: "assistant", Content: conv.Response},
-				)
-			}
+		flat = flattenConversations(nodes, tag, toolCalls)
+	} else {
+		for _, t := range distinctTags(nodes) {
+			flat = append(flat, flattenConversations(nodes, t, toolCalls)...)
 		}
 	}
-	messages = append(messages, Message{Role: "user", Content: prompt})
-
-	url := inferenceURL + "/v1/agents/heroku"
-	payload := RequestPayload{
-		Model:    "claude-4-sonnet",
-		Messages: messages,
-	}
-	body, err := json.Marshal(payload)
-	if err != nil {
-		return "", fmt.Errorf("failed to create payload: %v", err)
+	for i := range flat {
+		flat[i].ID = i + 1
 	}
+	return flat, nil
+}
 
-	req, err := http.NewRequest("POST", url, strings.NewReader(string(body)))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+// distinctTags returns the tags present in nodes, in first-seen order.
+func distinctTags(nodes []Node) []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		if n.Tag != "" && !seen[n.Tag] {
+			seen[n.Tag] = true
+			tags = append(tags, n.Tag)
+		}
 	}
-	req.Header.Set("Authorization", "Bearer "+inferenceKey)
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-Forwarded-Proto", "https")
+	return tags
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// saveConversation appends a prompt/response turn to the store, recording
+// which provider and model produced the response.
+func saveConversation(prompt, response, tag, provider, model string) error {
+	return saveConversationWithTranscript(prompt, response, tag, provider, model, nil)
+}
+
+// saveConversationWithTranscript is saveConversation plus any tool-call
+// and tool-result turns the model produced along the way, so `navigate`
+// can show them inline between the prompt and the final answer.
+func saveConversationWithTranscript(prompt, response, tag, provider, model string, transcript []Message) error {
+	nodes, err := store.NodesByTag(context.Background(), tag)
 	if err != nil {
-		return "", fmt.Errorf("failed to call endpoint: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("response status %d: %s", resp.StatusCode, string(body))
+	parent, err := appendNode(tagTip(nodes, tag), tag, "user", prompt)
+	if err != nil {
+		return err
 	}
-
-	var fullResponse strings.Builder
-	reader := bufio.NewReader(resp.Body)
-	for {
-		line, err := reader.ReadString('\n')
-		if err == io.EOF {
-			break
-		}
+	for _, msg := range transcript {
+		parent, err = appendNode(parent, tag, msg.Role, msg.Content)
 		if err != nil {
-			return "", fmt.Errorf("failed to read stream: %v", err)
+			return err
 		}
-		if strings.HasPrefix(line, "data:") {
-			data := strings.TrimSpace(line[5:])
-			if data == "[DONE]" {
-				break
-			}
-			var responseData ResponseData
-			if err := json.Unmarshal([]byte(data), &responseData); err != nil {
-				fmt.Fprintf(os.Stderr, "Error parsing line: %v\n", err)
-				continue
-			}
-			if len(responseData.Choices) > 0 && responseData.Choices[0].Message.Content != "" {
-				fullResponse.WriteString(responseData.Choices[0].Message.Content)
+		if msg.Role == "tool" && msg.ToolName != "" {
+			if err := store.RecordToolCall(context.Background(), parent, msg.ToolName, msg.ToolArguments, msg.Content); err != nil {
+				return err
 			}
 		}
 	}
-	if fullResponse.String() == "" {
-		return "", fmt.Errorf("empty response from model; check prompt or add-on configuration")
+	_, err = appendNodeWithMeta(parent, tag, "assistant", response, provider, model)
+	return err
+}
+
+// buildMessages assembles the message list for a tag: its existing
+// history (if any) followed by the new prompt.
+func buildMessages(prompt, tag string) ([]Message, error) {
+	history, err := loadHistory(tag)
+	if err != nil {
+		return nil, err
+	}
+	var messages []Message
+	for _, conv := range history {
+		messages = append(messages,
+			Message{Role: "user", Content: conv.Prompt},
+			Message{Role: "assistant", Content: conv.Response},
+		)
 	}
-	return fullResponse.String(), nil
+	messages = append(messages, Message{Role: "user", Content: prompt})
+	return messages, nil
 }
 
 // viewHistory displays conversations, optionally filtered by tag
 func viewHistory(tag string) error {
-	history, err := loadHistory()
+	history, err := loadHistory(tag)
 	if err != nil {
 		return err
 	}
 	if len(history) == 0 {
-		color.Yellow("⚠️ No history found.")
+		if tag != "" {
+			color.Yellow("⚠️ No conversations found with tag '%s'.", tag)
+		} else {
+			color.Yellow("⚠️ No history found.")
+		}
 		return nil
 	}
-	found := false
 	for _, conv := range history {
-		if tag == "" || conv.Tag == tag {
-			color.Cyan("📜 Conversation %d (%s) [Tag: %s]:", conv.ID, conv.Timestamp, conv.Tag)
-			fmt.Printf("  Prompt: %s\n", conv.Prompt)
-			fmt.Printf("  Response: %s\n\n", conv.Response)
-			found = true
+		color.Cyan("📜 Conversation %d (%s) [Tag: %s]:", conv.ID, conv.Timestamp, conv.Tag)
+		fmt.Printf("  Prompt: %s\n", conv.Prompt)
+		for _, step := range conv.ToolTrace {
+			fmt.Printf("  %s\n", step)
+		}
+		fmt.Printf("  Response: %s\n", conv.Response)
+		if conv.Provider != "" {
+			fmt.Printf("  Provider: %s (%s)\n", conv.Provider, conv.Model)
 		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// searchHistory runs a full-text search over prompts/responses via the
+// backend's FTS5 index and prints the matching turns.
+func searchHistory(query string) error {
+	nodes, err := store.SearchNodes(context.Background(), quoteFTSQuery(query))
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		color.Yellow("⚠️ No matches for %q.", query)
+		return nil
 	}
-	if !found && tag != "" {
-		color.Yellow("⚠️ No conversations found with tag '%s'.", tag)
+	for _, n := range nodes {
+		color.Cyan("📜 [%s] %s (%s):", n.Tag, n.Role, n.Timestamp)
+		fmt.Printf("  %s\n\n", n.Content)
 	}
 	return nil
 }
 
 // navigateConversations allows interactive navigation through conversations
 func navigateConversations(tag string) {
-	history, err := loadHistory()
+	filteredHistory, err := loadHistory(tag)
 	if err != nil {
 		color.Red("❌ Error loading history: %v", err)
 		return
 	}
-	var filteredHistory []Conversation
-	if tag == "" {
-		filteredHistory = history
-	} else {
-		for _, conv := range history {
-			if conv.Tag == tag {
-				filteredHistory = append(filteredHistory, conv)
-			}
-		}
-	}
 	if len(filteredHistory) == 0 {
 		if tag == "" {
 			color.Yellow("⚠️ No history found.")
@@ -240,7 +304,13 @@ func navigateConversations(tag string) {
 			conv := filteredHistory[currentIndex]
 			color.Cyan("\n📜 Current Conversation %d (%s) [Tag: %s]:", conv.ID, conv.Timestamp, conv.Tag)
 			fmt.Printf("  Prompt: %s\n", conv.Prompt)
+			for _, step := range conv.ToolTrace {
+				fmt.Printf("  %s\n", step)
+			}
 			fmt.Printf("  Response: %s\n", conv.Response)
+			if conv.Provider != "" {
+				fmt.Printf("  Provider: %s (%s)\n", conv.Provider, conv.Model)
+			}
 		}
 		fmt.Print(color.MagentaString("Navigate (next/previous/select <ID>/back): "))
 		scanner := bufio.NewScanner(os.Stdin)
@@ -287,32 +357,139 @@ func navigateConversations(tag string) {
 }
 
 func main() {
+	backend, err := openBackend()
+	if err != nil {
+		color.Red("❌ Error opening %s: %v", dbPath, err)
+		os.Exit(1)
+	}
+	store = backend
+	defer store.Close()
+
+	// rootCtx is cancelled on SIGINT/SIGTERM, so Ctrl-C during a long
+	// generation unblocks the in-flight HTTP read instead of leaving the
+	// connection (and the process) hanging. See --timeout/--idle-timeout
+	// below for the other two ways a request can be cut short.
+	rootCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var providerFlag string
+	var yoloFlag bool
+	var timeoutFlag time.Duration
+	var idleTimeoutFlag time.Duration
+	var stdinFlag bool
+	var formatFlag string
+	var noSaveFlag bool
+
 	var rootCmd = &cobra.Command{
-		Use:   "herochat <tag> <prompt>",
-		Short: "A CLI to chat with Heroku's Claude-4-Sonnet model",
-		Args:  cobra.MinimumNArgs(2), // Require tag and at least one word for prompt
+		Use:   "herochat <tag> [prompt]",
+		Short: "A CLI to chat with Heroku's Claude-4-Sonnet model and other providers",
+		Args:  cobra.MinimumNArgs(1), // tag is required; prompt may come from stdin instead
 		Run: func(cmd *cobra.Command, args []string) {
 			tag := args[0]
 			prompt := strings.Join(args[1:], " ") // Join all args after tag as prompt
-			response, err := callHeroku(prompt, tag)
+
+			if stdinFlag || isStdinPiped() {
+				piped, err := readStdin()
+				if err != nil {
+					color.Red("❌ Error: %v", err)
+					return
+				}
+				if prompt != "" {
+					prompt = prompt + "\n\n" + piped
+				} else {
+					prompt = piped
+				}
+			}
+			if prompt == "" {
+				color.Red("❌ Error: no prompt given; pass one as an argument, pipe it on stdin, or use --stdin")
+				return
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				color.Red("❌ Error loading config: %v", err)
+				return
+			}
+			provider, err := NewProvider(resolveProviderName(providerFlag, cfg), cfg)
+			if err != nil {
+				color.Red("❌ Error: %v", err)
+				return
+			}
+
+			messages, err := buildMessages(prompt, tag)
 			if err != nil {
 				color.Red("❌ Error: %v", err)
 				return
 			}
-			color.Green("✅ Response: %s", response)
-			if err := saveConversation(prompt, response, tag); err != nil {
-				color.Red("❌ Error saving conversation: %v", err)
+
+			ctx := rootCtx
+			if timeoutFlag > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, timeoutFlag)
+				defer cancel()
+			}
+
+			var response string
+			var transcript []Message
+			if provider.Name() == "heroku" {
+				// Tool-calling is currently only wired up against Heroku;
+				// see runToolLoop.
+				response, transcript, err = runToolLoop(ctx, messages, yoloFlag, idleTimeoutFlag)
 			} else {
-				color.Green("✅ Conversation saved in conversations.json with tag '%s'", tag)
+				response, err = provider.Complete(ctx, messages, CompletionOptions{IdleTimeout: idleTimeoutFlag})
+			}
+			if err != nil {
+				color.Red("❌ Error: %v", err)
+				return
+			}
+
+			if !noSaveFlag {
+				if err := saveConversationWithTranscript(prompt, response, tag, provider.Name(), provider.Model(), transcript); err != nil {
+					color.Red("❌ Error saving conversation: %v", err)
+				}
+			}
+
+			var toolCalls []ToolCall
+			for _, m := range transcript {
+				toolCalls = append(toolCalls, m.ToolCalls...)
+			}
+			switch formatFlag {
+			case "json":
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(scriptResult{Prompt: prompt, Response: response, ToolCalls: toolCalls, Tokens: estimateTokens(prompt + response)}); err != nil {
+					color.Red("❌ Error encoding response: %v", err)
+				}
+			case "text":
+				fmt.Println(response)
+			default: // "pretty"
+				color.Green("✅ Response: %s", response)
+				if !noSaveFlag {
+					color.Green("✅ Conversation saved in %s with tag '%s'", dbPath, tag)
+				}
 			}
 		},
 	}
+	rootCmd.PersistentFlags().StringVar(&providerFlag, "provider", "", "backend to use (heroku, openai, anthropic, ollama, gemini); defaults to $HEROCHAT_PROVIDER or the config file")
+	rootCmd.PersistentFlags().BoolVar(&yoloFlag, "yolo", false, "run tool calls without asking for confirmation")
+	rootCmd.PersistentFlags().DurationVar(&timeoutFlag, "timeout", 2*time.Minute, "overall deadline for a request; 0 disables it")
+	rootCmd.PersistentFlags().DurationVar(&idleTimeoutFlag, "idle-timeout", 30*time.Second, "abort if no data arrives between stream chunks for this long; 0 disables it")
+	rootCmd.Flags().BoolVar(&stdinFlag, "stdin", false, "read the prompt (or a suffix appended to it) from stdin; auto-enabled when stdin isn't a terminal")
+	rootCmd.Flags().StringVar(&formatFlag, "format", "pretty", "output format: pretty (colored), text (plain), or json (for scripting)")
+	rootCmd.Flags().BoolVar(&noSaveFlag, "no-save", false, "don't persist this turn to history")
 
+	var searchFlag string
 	var historyCmd = &cobra.Command{
 		Use:   "history [tag]",
-		Short: "View conversation history, optionally filtered by tag",
+		Short: "View conversation history, optionally filtered by tag or full-text search",
 		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
+			if searchFlag != "" {
+				if err := searchHistory(searchFlag); err != nil {
+					color.Red("❌ Error searching history: %v", err)
+				}
+				return
+			}
 			tag := ""
 			if len(args) > 0 {
 				tag = args[0]
@@ -322,6 +499,7 @@ func main() {
 			}
 		},
 	}
+	historyCmd.Flags().StringVar(&searchFlag, "search", "", "full-text search prompts/responses instead of listing by tag")
 
 	var navigateCmd = &cobra.Command{
 		Use:   "navigate [tag]",
@@ -336,7 +514,7 @@ func main() {
 		},
 	}
 
-	rootCmd.AddCommand(historyCmd, navigateCmd)
+	rootCmd.AddCommand(historyCmd, navigateCmd, newTUICmd(), newToolsCmd(), newMigrateCmd())
 	if err := rootCmd.Execute(); err != nil {
 		color.Red("❌ err: %v", err)
 		os.Exit(1)
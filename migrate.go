@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// legacyConversation mirrors the pre-SQLite Conversation shape, kept here
+// only so `herochat migrate` can still read an old conversations.json.
+type legacyConversation struct {
+	ID        int    `json:"conversation_id"`
+	Prompt    string `json:"prompt"`
+	Response  string `json:"response"`
+	Timestamp string `json:"timestamp"`
+	Tag       string `json:"tag,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+}
+
+// legacyStore mirrors the Node-tree JSON schema introduced right before
+// the SQLite migration (schema version 2), for conversations.json files
+// written by that brief window of herochat.
+type legacyStore struct {
+	Version int    `json:"version"`
+	Nodes   []Node `json:"nodes"`
+}
+
+// loadLegacyNodes reads conversations.json in either shape it has ever
+// been written in and returns it as a flat list of nodes ready to replay
+// into the SQLite backend.
+func loadLegacyNodes(path string) ([]Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	var versioned legacyStore
+	if err := json.Unmarshal(data, &versioned); err == nil && versioned.Version > 0 {
+		return versioned.Nodes, nil
+	}
+
+	var flat []legacyConversation
+	if err := json.Unmarshal(data, &flat); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	var nodes []Node
+	lastIDByTag := map[string]string{}
+	for _, conv := range flat {
+		// flattenConversations treats an assistant node with no Provider as
+		// a tool-call-requesting turn that isn't the final answer yet. The
+		// legacy flat format predates tool-calling, so every assistant node
+		// here IS a final answer; stamp a sentinel provider when the old
+		// file didn't record one so the turn still surfaces in history.
+		provider := conv.Provider
+		if provider == "" {
+			provider = "legacy"
+		}
+		userNode := Node{
+			ID:        newNodeID(),
+			ParentID:  lastIDByTag[conv.Tag],
+			Tag:       conv.Tag,
+			Role:      "user",
+			Content:   conv.Prompt,
+			Timestamp: conv.Timestamp,
+		}
+		assistantNode := Node{
+			ID:        newNodeID(),
+			ParentID:  userNode.ID,
+			Tag:       conv.Tag,
+			Role:      "assistant",
+			Content:   conv.Response,
+			Provider:  provider,
+			Model:     conv.Model,
+			Timestamp: conv.Timestamp,
+		}
+		nodes = append(nodes, userNode, assistantNode)
+		lastIDByTag[conv.Tag] = assistantNode.ID
+	}
+	return nodes, nil
+}
+
+// newMigrateCmd builds the one-time `herochat migrate` command that
+// ingests an existing conversations.json into herochat.db.
+func newMigrateCmd() *cobra.Command {
+	var path string
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Import an existing conversations.json into herochat.db",
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				color.Yellow("⚠️ %s not found, nothing to migrate.", path)
+				return
+			}
+			nodes, err := loadLegacyNodes(path)
+			if err != nil {
+				color.Red("❌ %v", err)
+				return
+			}
+			ctx := context.Background()
+			for _, n := range nodes {
+				if n.Timestamp == "" {
+					n.Timestamp = time.Now().Format(time.RFC3339)
+				}
+				if err := store.AppendNode(ctx, n); err != nil {
+					color.Red("❌ Failed to migrate node %s: %v", n.ID, err)
+					return
+				}
+			}
+			color.Green("✅ Migrated %d nodes from %s into %s", len(nodes), path, dbPath)
+		},
+	}
+	cmd.Flags().StringVar(&path, "file", "conversations.json", "legacy conversations.json file to import")
+	return cmd
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Chunk is one piece of a streamed completion. Cancelled is set on the
+// final chunk when the stream ended early because of a cancelled context
+// or an expired idle timeout, rather than a normal [DONE]/EOF.
+type Chunk struct {
+	Content   string
+	Done      bool
+	Cancelled bool
+}
+
+// CompletionOptions carries per-request overrides on top of the provider's
+// configured defaults.
+type CompletionOptions struct {
+	Model string
+	// IdleTimeout, if non-zero, aborts the stream if no SSE line arrives
+	// within that window, so a hung connection doesn't block forever.
+	IdleTimeout time.Duration
+}
+
+// ChatCompletionProvider is implemented by every backend herochat can talk
+// to. StreamCompletion is the primitive; Complete is a convenience wrapper
+// that drains the channel for callers that just want the final string.
+type ChatCompletionProvider interface {
+	Name() string
+	Model() string
+	StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error)
+	Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error)
+}
+
+// NewProvider builds the provider registered under name using cfg. It is
+// the single place that maps a provider name to an implementation.
+func NewProvider(name string, cfg Config) (ChatCompletionProvider, error) {
+	pc, ok := cfg.Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %q (check ~/.config/herochat/config.yaml)", name)
+	}
+	switch name {
+	case "heroku":
+		return &herokuProvider{pc: pc}, nil
+	case "openai":
+		return &openAIProvider{pc: pc}, nil
+	case "anthropic":
+		return &anthropicProvider{pc: pc}, nil
+	case "ollama":
+		return &ollamaProvider{pc: pc}, nil
+	case "gemini":
+		return &geminiProvider{pc: pc}, nil
+	default:
+		return nil, fmt.Errorf("unsupported provider %q", name)
+	}
+}
+
+// drainToString is the shared Complete() implementation: run
+// StreamCompletion and concatenate every chunk's content.
+func drainToString(ctx context.Context, p ChatCompletionProvider, messages []Message, opts CompletionOptions) (string, error) {
+	chunks, err := p.StreamCompletion(ctx, messages, opts)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	var cancelled bool
+	for c := range chunks {
+		b.WriteString(c.Content)
+		if c.Cancelled {
+			cancelled = true
+		}
+	}
+	if b.Len() == 0 {
+		if cancelled {
+			return "", fmt.Errorf("request to %s cancelled before any response was received", p.Name())
+		}
+		return "", fmt.Errorf("empty response from %s; check prompt or provider configuration", p.Name())
+	}
+	if cancelled {
+		b.WriteString("\n\n[cancelled: response interrupted]")
+	}
+	return b.String(), nil
+}
+
+func modelOrDefault(opts CompletionOptions, fallback string) string {
+	if opts.Model != "" {
+		return opts.Model
+	}
+	return fallback
+}
+
+// readSSE reads "data: {...}" lines off resp (closing its body before it
+// returns) and calls onEvent with each decoded ResponseData, until the
+// stream ends normally (EOF or a "[DONE]" line). Heroku's direct
+// tool-calling requests (toolloop.go) and every OpenAI-compatible
+// provider below share this one parsing/idle-timeout loop instead of
+// keeping their own copies in sync by hand.
+//
+// idleTimeout, if non-zero, is reset after every line read; if it ever
+// fires, a goroutine closes resp.Body, which unblocks the in-flight
+// ReadString with an error so the stream ends instead of hanging forever.
+// The returned cancelled flag is true when that happened, or when ctx
+// ended on its own (Ctrl-C, --timeout) — as opposed to a genuine I/O
+// error, which is returned as err instead so callers don't mistake a
+// real failure for an intentional stop.
+func readSSE(ctx context.Context, resp *http.Response, idleTimeout time.Duration, onEvent func(ResponseData)) (cancelled bool, err error) {
+	defer resp.Body.Close()
+
+	var idle *time.Timer
+	var idleFired atomic.Bool
+	if idleTimeout > 0 {
+		idle = time.AfterFunc(idleTimeout, func() {
+			idleFired.Store(true)
+			resp.Body.Close()
+		})
+		defer idle.Stop()
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, rerr := reader.ReadString('\n')
+		if idle != nil {
+			idle.Reset(idleTimeout)
+		}
+		if rerr == io.EOF {
+			return false, nil
+		}
+		if rerr != nil {
+			if idleFired.Load() || ctx.Err() != nil {
+				return true, nil
+			}
+			return false, fmt.Errorf("failed to read stream: %v", rerr)
+		}
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(line[5:])
+		if data == "[DONE]" {
+			return false, nil
+		}
+		var responseData ResponseData
+		if err := json.Unmarshal([]byte(data), &responseData); err != nil {
+			continue
+		}
+		onEvent(responseData)
+	}
+}
+
+// sseStream issues req and streams an OpenAI-compatible
+// choices[0].message.content payload onto a Chunk channel via readSSE.
+// Heroku, OpenAI and Ollama (in OpenAI-compat mode) all speak this shape,
+// so they share this helper.
+func sseStream(req *http.Request, idleTimeout time.Duration) (<-chan Chunk, error) {
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("response status %d: %s", resp.StatusCode, string(body))
+	}
+
+	out := make(chan Chunk)
+	go func() {
+		defer close(out)
+		cancelled, _ := readSSE(req.Context(), resp, idleTimeout, func(rd ResponseData) {
+			if len(rd.Choices) > 0 && rd.Choices[0].Message.Content != "" {
+				out <- Chunk{Content: rd.Choices[0].Message.Content}
+			}
+		})
+		// A genuine read error (non-nil, non-cancelled) just ends the
+		// stream with whatever content already arrived; Chunk has no
+		// error field for callers that only want text.
+		out <- Chunk{Done: true, Cancelled: cancelled}
+	}()
+	return out, nil
+}
+
+// herokuProvider talks to Heroku Managed Inference.
+type herokuProvider struct{ pc ProviderConfig }
+
+func (p *herokuProvider) Name() string  { return "heroku" }
+func (p *herokuProvider) Model() string { return p.pc.Model }
+
+func (p *herokuProvider) StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error) {
+	key := os.Getenv(p.pc.KeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s not configured", p.pc.KeyEnv)
+	}
+	payload := RequestPayload{Model: modelOrDefault(opts, p.pc.Model), Messages: messages}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.pc.BaseURL+"/v1/agents/heroku", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "https")
+	return sseStream(req, opts.IdleTimeout)
+}
+
+func (p *herokuProvider) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return drainToString(ctx, p, messages, opts)
+}
+
+// openAIProvider talks to the OpenAI chat completions API.
+type openAIProvider struct{ pc ProviderConfig }
+
+func (p *openAIProvider) Name() string  { return "openai" }
+func (p *openAIProvider) Model() string { return p.pc.Model }
+
+func (p *openAIProvider) StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error) {
+	key := os.Getenv(p.pc.KeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s not configured", p.pc.KeyEnv)
+	}
+	payload := RequestPayload{Model: modelOrDefault(opts, p.pc.Model), Messages: messages}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.pc.BaseURL+"/v1/chat/completions", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+key)
+	req.Header.Set("Content-Type", "application/json")
+	return sseStream(req, opts.IdleTimeout)
+}
+
+func (p *openAIProvider) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return drainToString(ctx, p, messages, opts)
+}
+
+// ollamaProvider talks to a local Ollama server via its OpenAI-compatible
+// endpoint, so no API key is required.
+type ollamaProvider struct{ pc ProviderConfig }
+
+func (p *ollamaProvider) Name() string  { return "ollama" }
+func (p *ollamaProvider) Model() string { return p.pc.Model }
+
+func (p *ollamaProvider) StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error) {
+	payload := RequestPayload{Model: modelOrDefault(opts, p.pc.Model), Messages: messages}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.pc.BaseURL+"/v1/chat/completions", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return sseStream(req, opts.IdleTimeout)
+}
+
+func (p *ollamaProvider) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return drainToString(ctx, p, messages, opts)
+}
+
+// anthropicMessageResponse is the shape of a non-streamed call to
+// Anthropic's /v1/messages endpoint.
+type anthropicMessageResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+}
+
+// anthropicProvider talks directly to api.anthropic.com. Its streaming
+// events use the Messages API's content_block_delta shape, which doesn't
+// fit sseStream's OpenAI-style choices[] parser, so this issues a plain
+// (stream:false) request instead and hands the whole reply back as one
+// Chunk.
+type anthropicProvider struct{ pc ProviderConfig }
+
+func (p *anthropicProvider) Name() string  { return "anthropic" }
+func (p *anthropicProvider) Model() string { return p.pc.Model }
+
+func (p *anthropicProvider) StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error) {
+	key := os.Getenv(p.pc.KeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s not configured", p.pc.KeyEnv)
+	}
+	payload := map[string]interface{}{
+		"model":      modelOrDefault(opts, p.pc.Model),
+		"messages":   messages,
+		"max_tokens": 4096,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload: %v", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", p.pc.BaseURL+"/v1/messages", strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", "2023-06-01")
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("response status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var parsed anthropicMessageResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var text strings.Builder
+	for _, block := range parsed.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+
+	out := make(chan Chunk, 2)
+	out <- Chunk{Content: text.String()}
+	out <- Chunk{Done: true}
+	close(out)
+	return out, nil
+}
+
+func (p *anthropicProvider) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return drainToString(ctx, p, messages, opts)
+}
+
+// geminiContent and geminiPart are Gemini's generateContent request/
+// response shape: {"contents":[{"role":"user","parts":[{"text":"..."}]}]}.
+// Gemini calls the assistant role "model" rather than "assistant".
+type geminiContent struct {
+	Role  string       `json:"role"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+// toGeminiContents maps herochat's OpenAI-style messages onto Gemini's
+// contents/parts shape.
+func toGeminiContents(messages []Message) []geminiContent {
+	contents := make([]geminiContent, 0, len(messages))
+	for _, m := range messages {
+		role := m.Role
+		switch role {
+		case "assistant":
+			role = "model"
+		case "tool":
+			role = "user" // Gemini has no generic tool role in this shape
+		}
+		contents = append(contents, geminiContent{Role: role, Parts: []geminiPart{{Text: m.Content}}})
+	}
+	return contents
+}
+
+// geminiGenerateContentResponse is the shape of a non-streamed call to
+// Gemini's :generateContent endpoint.
+type geminiGenerateContentResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []geminiPart `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+}
+
+// geminiProvider talks to the Gemini generateContent API. It uses the
+// non-streaming :generateContent endpoint rather than
+// :streamGenerateContent, since the latter's response is a JSON array of
+// full candidate objects rather than sseStream's "data:" line shape.
+type geminiProvider struct{ pc ProviderConfig }
+
+func (p *geminiProvider) Name() string  { return "gemini" }
+func (p *geminiProvider) Model() string { return p.pc.Model }
+
+func (p *geminiProvider) StreamCompletion(ctx context.Context, messages []Message, opts CompletionOptions) (<-chan Chunk, error) {
+	key := os.Getenv(p.pc.KeyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("%s not configured", p.pc.KeyEnv)
+	}
+	payload := map[string]interface{}{"contents": toGeminiContents(messages)}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create payload: %v", err)
+	}
+	url := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", p.pc.BaseURL, modelOrDefault(opts, p.pc.Model), key)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("response status %d: %s", resp.StatusCode, string(data))
+	}
+
+	var parsed geminiGenerateContentResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	var text strings.Builder
+	if len(parsed.Candidates) > 0 {
+		for _, part := range parsed.Candidates[0].Content.Parts {
+			text.WriteString(part.Text)
+		}
+	}
+
+	out := make(chan Chunk, 2)
+	out <- Chunk{Content: text.String()}
+	out <- Chunk{Done: true}
+	close(out)
+	return out, nil
+}
+
+func (p *geminiProvider) Complete(ctx context.Context, messages []Message, opts CompletionOptions) (string, error) {
+	return drainToString(ctx, p, messages, opts)
+}
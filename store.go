@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Backend is the persistence layer for the conversation tree. Nodes are
+// appended one at a time rather than read-modify-written as a whole file,
+// so concurrent `herochat` invocations no longer race on a single JSON
+// blob the way they did with conversations.json.
+type Backend interface {
+	AppendNode(ctx context.Context, n Node) error
+	AllNodes(ctx context.Context) ([]Node, error)
+	NodesByTag(ctx context.Context, tag string) ([]Node, error)
+	SearchNodes(ctx context.Context, query string) ([]Node, error)
+	// RecordToolCall persists a tool invocation as a distinct tool_calls
+	// row, linked back to the "tool" role conversation node that carries
+	// its result in the default history view.
+	RecordToolCall(ctx context.Context, conversationID, toolName, arguments, result string) error
+	// ToolCallsByConversationIDs looks up the recorded tool_calls rows for a
+	// batch of "tool" role node IDs at once, keyed by conversation_id, so
+	// flattenConversations can show which tool produced each result inline
+	// instead of just the bare content.
+	ToolCallsByConversationIDs(ctx context.Context, conversationIDs []string) (map[string]ToolCallRecord, error)
+	Close() error
+}
+
+// ToolCallRecord is the name/arguments half of a tool_calls row, looked up
+// by the "tool" role node ID that carries the matching result.
+type ToolCallRecord struct {
+	ToolName  string
+	Arguments string
+}
+
+const dbPath = "herochat.db"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS conversations (
+	id         TEXT PRIMARY KEY,
+	parent_id  TEXT,
+	tag        TEXT,
+	role       TEXT NOT NULL,
+	content    TEXT NOT NULL,
+	provider   TEXT,
+	model      TEXT,
+	created_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_conversations_tag ON conversations(tag);
+CREATE INDEX IF NOT EXISTS idx_conversations_parent_id ON conversations(parent_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+	tag         TEXT PRIMARY KEY,
+	last_used   TEXT
+);
+
+CREATE TABLE IF NOT EXISTS tool_calls (
+	id              TEXT PRIMARY KEY,
+	conversation_id TEXT NOT NULL REFERENCES conversations(id),
+	tool_name       TEXT NOT NULL,
+	arguments       TEXT,
+	result          TEXT,
+	created_at      TEXT NOT NULL
+);
+
+CREATE VIRTUAL TABLE IF NOT EXISTS conversations_fts USING fts5(
+	id UNINDEXED,
+	content
+);
+`
+
+// sqliteBackend stores the conversation tree in a local SQLite file via
+// modernc.org/sqlite (pure Go, no CGO required).
+type sqliteBackend struct {
+	db *sql.DB
+}
+
+func openSQLiteBackend(path string) (*sqliteBackend, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	// SQLite allows only one writer at a time; WAL lets readers proceed
+	// alongside it, and busy_timeout makes a second writer block and
+	// retry instead of failing immediately with SQLITE_BUSY when two
+	// `herochat` processes append nodes at the same time. MaxOpenConns(1)
+	// keeps database/sql from handing out a second connection that would
+	// otherwise race for the write lock on its own.
+	if _, err := db.Exec(`PRAGMA journal_mode=WAL; PRAGMA busy_timeout=5000;`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to set pragmas: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to apply schema: %v", err)
+	}
+	return &sqliteBackend{db: db}, nil
+}
+
+func (b *sqliteBackend) AppendNode(ctx context.Context, n Node) error {
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO conversations (id, parent_id, tag, role, content, provider, model, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID, n.ParentID, n.Tag, n.Role, n.Content, n.Provider, n.Model, n.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert conversation node: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO conversations_fts (id, content) VALUES (?, ?)`, n.ID, n.Content); err != nil {
+		return fmt.Errorf("failed to index node for search: %v", err)
+	}
+	if n.Tag != "" {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO tags (tag, last_used) VALUES (?, ?)
+			 ON CONFLICT(tag) DO UPDATE SET last_used = excluded.last_used`,
+			n.Tag, n.Timestamp); err != nil {
+			return fmt.Errorf("failed to update tags: %v", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (b *sqliteBackend) RecordToolCall(ctx context.Context, conversationID, toolName, arguments, result string) error {
+	_, err := b.db.ExecContext(ctx,
+		`INSERT INTO tool_calls (id, conversation_id, tool_name, arguments, result, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?)`,
+		newNodeID(), conversationID, toolName, arguments, result, time.Now().Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("failed to record tool call: %v", err)
+	}
+	return nil
+}
+
+func (b *sqliteBackend) ToolCallsByConversationIDs(ctx context.Context, conversationIDs []string) (map[string]ToolCallRecord, error) {
+	calls := make(map[string]ToolCallRecord)
+	if len(conversationIDs) == 0 {
+		return calls, nil
+	}
+	placeholders := strings.Repeat("?,", len(conversationIDs))
+	placeholders = placeholders[:len(placeholders)-1]
+	args := make([]interface{}, len(conversationIDs))
+	for i, id := range conversationIDs {
+		args[i] = id
+	}
+	rows, err := b.db.QueryContext(ctx,
+		`SELECT conversation_id, tool_name, arguments FROM tool_calls WHERE conversation_id IN (`+placeholders+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool calls: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var conversationID string
+		var rec ToolCallRecord
+		if err := rows.Scan(&conversationID, &rec.ToolName, &rec.Arguments); err != nil {
+			return nil, err
+		}
+		calls[conversationID] = rec
+	}
+	return calls, rows.Err()
+}
+
+func scanNodes(rows *sql.Rows) ([]Node, error) {
+	defer rows.Close()
+	var nodes []Node
+	for rows.Next() {
+		var n Node
+		var parentID, tag, provider, model sql.NullString
+		if err := rows.Scan(&n.ID, &parentID, &tag, &n.Role, &n.Content, &provider, &model, &n.Timestamp); err != nil {
+			return nil, err
+		}
+		n.ParentID, n.Tag, n.Provider, n.Model = parentID.String, tag.String, provider.String, model.String
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+const selectNodeColumns = `id, parent_id, tag, role, content, provider, model, created_at`
+
+func (b *sqliteBackend) AllNodes(ctx context.Context) ([]Node, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT `+selectNodeColumns+` FROM conversations ORDER BY rowid`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations: %v", err)
+	}
+	return scanNodes(rows)
+}
+
+func (b *sqliteBackend) NodesByTag(ctx context.Context, tag string) ([]Node, error) {
+	rows, err := b.db.QueryContext(ctx, `SELECT `+selectNodeColumns+` FROM conversations WHERE tag = ? ORDER BY rowid`, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query conversations for tag %q: %v", tag, err)
+	}
+	return scanNodes(rows)
+}
+
+func (b *sqliteBackend) SearchNodes(ctx context.Context, query string) ([]Node, error) {
+	rows, err := b.db.QueryContext(ctx, `
+		SELECT c.id, c.parent_id, c.tag, c.role, c.content, c.provider, c.model, c.created_at
+		FROM conversations_fts f
+		JOIN conversations c ON c.id = f.id
+		WHERE f.content MATCH ?
+		ORDER BY rank`, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search conversations: %v", err)
+	}
+	return scanNodes(rows)
+}
+
+func (b *sqliteBackend) Close() error {
+	return b.db.Close()
+}
+
+// openBackend opens the default SQLite-backed store at ./herochat.db,
+// creating it on first use.
+func openBackend() (Backend, error) {
+	return openSQLiteBackend(dbPath)
+}
+
+// quoteFTSQuery escapes a raw search phrase for FTS5's MATCH syntax by
+// wrapping it in double quotes, so punctuation in user queries doesn't get
+// interpreted as FTS5 query syntax.
+func quoteFTSQuery(q string) string {
+	return `"` + strings.ReplaceAll(q, `"`, `""`) + `"`
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// herokuChoice is one non-streamed pass over the Heroku SSE response,
+// accumulated across every "data:" line so a tool_calls finish reason and
+// the tool_calls it carries survive the chunking that streamMessages
+// otherwise just flattens into plain text. Cancelled is set when the
+// stream was cut short by an idle timeout or a cancelled context, so the
+// caller can still save the partial content with a clear marker.
+type herokuChoice struct {
+	Content      string
+	ToolCalls    []ToolCall
+	FinishReason string
+	Cancelled    bool
+}
+
+// requestHerokuCompletion sends messages (with the tool registry attached)
+// to Heroku and collects the single resulting choice, reusing providers.go's
+// readSSE for the actual parsing/idle-timeout loop instead of keeping a
+// second copy of it. idleTimeout, if non-zero, aborts the stream if no SSE
+// line arrives within that window; ctx's own deadline/cancellation (wired
+// to --timeout and SIGINT/SIGTERM in main) aborts it regardless of idle
+// activity.
+func requestHerokuCompletion(ctx context.Context, messages []Message, idleTimeout time.Duration) (herokuChoice, error) {
+	inferenceURL := os.Getenv("INFERENCE_URL")
+	if inferenceURL == "" {
+		inferenceURL = "https://eu.inference.heroku.com"
+	}
+	inferenceKey := os.Getenv("INFERENCE_KEY")
+	if inferenceKey == "" {
+		return herokuChoice{}, fmt.Errorf("INFERENCE_KEY not configured")
+	}
+
+	payload := RequestPayload{
+		Model:    "claude-4-sonnet",
+		Messages: messages,
+		Tools:    toolsAsPayload(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return herokuChoice{}, fmt.Errorf("failed to create payload: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", inferenceURL+"/v1/agents/heroku", strings.NewReader(string(body)))
+	if err != nil {
+		return herokuChoice{}, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+inferenceKey)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return herokuChoice{}, fmt.Errorf("failed to call endpoint: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return herokuChoice{}, fmt.Errorf("response status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result herokuChoice
+	cancelled, err := readSSE(ctx, resp, idleTimeout, func(rd ResponseData) {
+		if len(rd.Choices) == 0 {
+			return
+		}
+		choice := rd.Choices[0]
+		result.Content += choice.Message.Content
+		if len(choice.Message.ToolCalls) > 0 {
+			result.ToolCalls = append(result.ToolCalls, choice.Message.ToolCalls...)
+		}
+		if choice.FinishReason != "" {
+			result.FinishReason = choice.FinishReason
+		}
+	})
+	if err != nil {
+		return herokuChoice{}, err
+	}
+	result.Cancelled = cancelled
+	return result, nil
+}
+
+// runToolLoop drives the tool-calling conversation: it sends messages,
+// and whenever the model's finish reason is "tool_calls" it runs each
+// requested tool (after confirmation, unless yolo is set), appends the
+// results as "tool" role messages, and asks the model again. It returns
+// the final assistant text and the full list of turns appended along the
+// way (user/tool/assistant), so the caller can persist them all.
+//
+// If the stream is cut short by idleTimeout or ctx (--timeout, SIGINT,
+// SIGTERM), the loop stops immediately and returns whatever partial
+// content arrived with a "[cancelled]" marker appended, rather than an
+// error, so the partial turn still gets saved to history.
+func runToolLoop(ctx context.Context, messages []Message, yolo bool, idleTimeout time.Duration) (string, []Message, error) {
+	var appended []Message
+	for {
+		choice, err := requestHerokuCompletion(ctx, messages, idleTimeout)
+		if err != nil {
+			return "", appended, err
+		}
+
+		if choice.Cancelled {
+			content := choice.Content
+			if content == "" {
+				content = "[cancelled: response interrupted before any content arrived]"
+			} else {
+				content += "\n\n[cancelled: response interrupted]"
+			}
+			return content, appended, nil
+		}
+
+		if choice.FinishReason != "tool_calls" || len(choice.ToolCalls) == 0 {
+			return choice.Content, appended, nil
+		}
+
+		assistantMsg := Message{Role: "assistant", Content: choice.Content, ToolCalls: choice.ToolCalls}
+		messages = append(messages, assistantMsg)
+		appended = append(appended, assistantMsg)
+
+		for _, call := range choice.ToolCalls {
+			result := runSingleTool(ctx, call, yolo)
+			toolMsg := Message{
+				Role:          "tool",
+				Content:       result,
+				ToolCallID:    call.ID,
+				ToolName:      call.Function.Name,
+				ToolArguments: call.Function.Arguments,
+			}
+			messages = append(messages, toolMsg)
+			appended = append(appended, toolMsg)
+		}
+	}
+}
+
+// runSingleTool confirms, executes and formats the result of one tool
+// call, producing the text that gets fed back to the model as a "tool"
+// message even on failure or denial.
+func runSingleTool(ctx context.Context, call ToolCall, yolo bool) string {
+	rt, ok := toolRegistry[call.Function.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+	}
+	if !confirmToolCall(call, yolo) {
+		return "denied by user"
+	}
+
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		return fmt.Sprintf("error: invalid arguments: %v", err)
+	}
+
+	result, err := rt.Handler(ctx, args)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return result
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// ToolSpec describes a tool to the model: its name, a human description,
+// and a JSON-schema object for its parameters.
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]interface{}
+}
+
+// ToolHandler executes a tool call and returns the text result shown back
+// to the model.
+type ToolHandler func(ctx context.Context, args map[string]interface{}) (string, error)
+
+// registeredTool pairs a spec with its handler in the registry.
+type registeredTool struct {
+	Spec    ToolSpec
+	Handler ToolHandler
+}
+
+var toolRegistry = map[string]registeredTool{}
+
+// registerTool adds a tool to the registry. Built-in tools register
+// themselves from init() below.
+func registerTool(spec ToolSpec, handler ToolHandler) {
+	toolRegistry[spec.Name] = registeredTool{Spec: spec, Handler: handler}
+}
+
+func init() {
+	registerTool(ToolSpec{
+		Name:        "read_file",
+		Description: "Read a UTF-8 text file relative to the current working directory.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"path": map[string]interface{}{"type": "string"}},
+			"required":   []string{"path"},
+		},
+	}, readFileTool)
+
+	registerTool(ToolSpec{
+		Name:        "write_file",
+		Description: "Write (overwrite) a UTF-8 text file relative to the current working directory.",
+		Parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"path":    map[string]interface{}{"type": "string"},
+				"content": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"path", "content"},
+		},
+	}, writeFileTool)
+
+	registerTool(ToolSpec{
+		Name:        "list_dir",
+		Description: "List entries in a directory relative to the current working directory.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"path": map[string]interface{}{"type": "string"}},
+		},
+	}, listDirTool)
+
+	registerTool(ToolSpec{
+		Name:        "shell_exec",
+		Description: "Run a shell command in the current working directory and return its combined output.",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"command": map[string]interface{}{"type": "string"}},
+			"required":   []string{"command"},
+		},
+	}, shellExecTool)
+
+	registerTool(ToolSpec{
+		Name:        "http_get",
+		Description: "Issue an HTTP GET request and return the response body (truncated to 8KB).",
+		Parameters: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"url": map[string]interface{}{"type": "string"}},
+			"required":   []string{"url"},
+		},
+	}, httpGetTool)
+}
+
+// resolveInCWD joins path onto the current working directory and rejects
+// any result that escapes it, so tools can't read or write outside the
+// project the user launched herochat from.
+func resolveInCWD(path string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve working directory: %v", err)
+	}
+	full := filepath.Join(cwd, path)
+	rel, err := filepath.Rel(cwd, full)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("path %q escapes the working directory", path)
+	}
+	return full, nil
+}
+
+func argString(args map[string]interface{}, key string) (string, error) {
+	v, ok := args[key]
+	if !ok {
+		return "", fmt.Errorf("missing required argument %q", key)
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("argument %q must be a string", key)
+	}
+	return s, nil
+}
+
+func readFileTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, err := argString(args, "path")
+	if err != nil {
+		return "", err
+	}
+	full, err := resolveInCWD(path)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %q: %v", path, err)
+	}
+	return string(data), nil
+}
+
+func writeFileTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, err := argString(args, "path")
+	if err != nil {
+		return "", err
+	}
+	content, err := argString(args, "content")
+	if err != nil {
+		return "", err
+	}
+	full, err := resolveInCWD(path)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+		return "", fmt.Errorf("failed to write %q: %v", path, err)
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), path), nil
+}
+
+func listDirTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		path = "."
+	}
+	full, err := resolveInCWD(path)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(full)
+	if err != nil {
+		return "", fmt.Errorf("failed to list %q: %v", path, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			names = append(names, e.Name()+"/")
+		} else {
+			names = append(names, e.Name())
+		}
+	}
+	return strings.Join(names, "\n"), nil
+}
+
+func shellExecTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	command, err := argString(args, "command")
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(out), fmt.Errorf("command failed: %v", err)
+	}
+	return string(out), nil
+}
+
+func httpGetTool(ctx context.Context, args map[string]interface{}) (string, error) {
+	url, err := argString(args, "url")
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8*1024))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	return fmt.Sprintf("status %d\n%s", resp.StatusCode, body), nil
+}
+
+// toolsAsPayload converts the registry into the Tool list sent to Heroku.
+func toolsAsPayload() []Tool {
+	var tools []Tool
+	for _, rt := range toolRegistry {
+		tools = append(tools, Tool{
+			Type: "function",
+			Function: ToolFunction{
+				Name:        rt.Spec.Name,
+				Description: rt.Spec.Description,
+				Parameters:  rt.Spec.Parameters,
+			},
+		})
+	}
+	sort.Slice(tools, func(i, j int) bool { return tools[i].Function.Name < tools[j].Function.Name })
+	return tools
+}
+
+// confirmToolCall asks the user before running a tool, unless yolo skips
+// the prompt.
+func confirmToolCall(call ToolCall, yolo bool) bool {
+	if yolo {
+		return true
+	}
+	color.Yellow("🔧 Model wants to run %s(%s)", call.Function.Name, call.Function.Arguments)
+	fmt.Print(color.MagentaString("Allow? [y/N] "))
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Scan()
+	return strings.EqualFold(strings.TrimSpace(scanner.Text()), "y")
+}
+
+// newToolsCmd builds the `herochat tools` subcommand group.
+func newToolsCmd() *cobra.Command {
+	toolsCmd := &cobra.Command{
+		Use:   "tools",
+		Short: "Inspect the tools available to the model",
+	}
+	toolsCmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the registered tools and their descriptions",
+		Run: func(cmd *cobra.Command, args []string) {
+			names := make([]string, 0, len(toolRegistry))
+			for name := range toolRegistry {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				rt := toolRegistry[name]
+				color.Cyan("🔧 %s", name)
+				fmt.Printf("  %s\n", rt.Spec.Description)
+			}
+		},
+	})
+	return toolsCmd
+}
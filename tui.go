@@ -0,0 +1,397 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/glamour"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// tuiModel is the Bubble Tea model backing `herochat tui`. It keeps the
+// tag's nodes in memory and renders the path from the tag's root to
+// whichever node is currently focused, so the user can walk branches with
+// vi-like keys and fork a new branch by editing a past turn.
+type tuiModel struct {
+	nodes    []Node
+	tag      string
+	path     []Node   // oldest first, the branch currently in view
+	cursor   int      // index into path of the focused turn
+	renderer *glamour.TermRenderer
+	status   string
+	width    int
+	height   int
+
+	provider     ChatCompletionProvider
+	streaming    bool
+	streamBuf    strings.Builder
+	streamParent string // ParentID the in-flight assistant reply will be appended under
+	chunks       <-chan Chunk
+
+	tagSelect bool // true while the "t" tag switcher list is open
+	tags      []string
+	tagCursor int
+}
+
+func newTUIModel(nodes []Node, tag string, provider ChatCompletionProvider) tuiModel {
+	renderer, _ := glamour.NewTermRenderer(glamour.WithAutoStyle())
+	tip := tagTip(nodes, tag)
+	return tuiModel{
+		nodes:    nodes,
+		tag:      tag,
+		path:     leafPath(nodes, tip),
+		renderer: renderer,
+		provider: provider,
+	}
+}
+
+// chunkMsg and streamDoneMsg drive the live-streaming response pane: each
+// Chunk read off the provider's channel is forwarded as a chunkMsg so the
+// view can redraw with partial content as it arrives, and streamDoneMsg
+// fires once the channel closes (or errors), at which point the full reply
+// is appended as an assistant node.
+type chunkMsg Chunk
+type streamDoneMsg struct{ err error }
+
+// waitForChunk reads the next Chunk off sub and reports it as a tea.Msg.
+// Update re-issues this command after every chunkMsg so the stream keeps
+// being drained until the channel closes.
+func waitForChunk(sub <-chan Chunk) tea.Cmd {
+	return func() tea.Msg {
+		chunk, ok := <-sub
+		if !ok {
+			return streamDoneMsg{}
+		}
+		return chunkMsg(chunk)
+	}
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		return m, nil
+
+	case chunkMsg:
+		m.streamBuf.WriteString(msg.Content)
+		if msg.Done {
+			return m.finishStream(msg.Cancelled)
+		}
+		return m, waitForChunk(m.chunks)
+
+	case streamDoneMsg:
+		return m.finishStream(false)
+
+	case tea.KeyMsg:
+		if m.streaming {
+			// Ignore input while a response is streaming in; the pane
+			// below is still redrawing on every chunk.
+			return m, nil
+		}
+		if m.tagSelect {
+			return m.updateTagSelect(msg)
+		}
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		case "j", "down":
+			if m.cursor < len(m.path)-1 {
+				m.cursor++
+			}
+		case "k", "up":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "e":
+			return m.editAndBranch()
+		case "tab":
+			m.cycleSibling()
+		case "t":
+			m.openTagSelect()
+		}
+	}
+	return m, nil
+}
+
+// openTagSelect lists every tag with at least one node and focuses the one
+// currently in view, ready for j/k/enter in updateTagSelect.
+func (m *tuiModel) openTagSelect() {
+	m.tags = distinctTags(m.nodes)
+	m.tagCursor = 0
+	for i, t := range m.tags {
+		if t == m.tag {
+			m.tagCursor = i
+			break
+		}
+	}
+	m.tagSelect = true
+	m.status = ""
+}
+
+// updateTagSelect handles keys while the tag switcher list is open: j/k to
+// move, enter to switch the view to the selected tag, esc/q to cancel.
+func (m tuiModel) updateTagSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q":
+		m.tagSelect = false
+	case "j", "down":
+		if m.tagCursor < len(m.tags)-1 {
+			m.tagCursor++
+		}
+	case "k", "up":
+		if m.tagCursor > 0 {
+			m.tagCursor--
+		}
+	case "enter":
+		if m.tagCursor < len(m.tags) {
+			m.tag = m.tags[m.tagCursor]
+			m.path = leafPath(m.nodes, tagTip(m.nodes, m.tag))
+			m.cursor = len(m.path) - 1
+			m.status = fmt.Sprintf("switched to tag '%s'", m.tag)
+		}
+		m.tagSelect = false
+	}
+	return m, nil
+}
+
+// finishStream appends the accumulated streamed reply as an assistant node
+// under streamParent, once the provider's channel closes.
+func (m tuiModel) finishStream(cancelled bool) (tea.Model, tea.Cmd) {
+	m.streaming = false
+	content := m.streamBuf.String()
+	if cancelled {
+		content += "\n\n[cancelled: response interrupted]"
+	}
+	assistantID, err := appendNodeWithMeta(m.streamParent, m.tag, "assistant", content, m.provider.Name(), m.provider.Model())
+	if err != nil {
+		m.status = fmt.Sprintf("❌ failed to save reply: %v", err)
+		return m, nil
+	}
+	m.nodes = append(m.nodes, Node{ID: assistantID, ParentID: m.streamParent, Tag: m.tag, Role: "assistant", Content: content, Provider: m.provider.Name(), Model: m.provider.Model()})
+	m.path = leafPath(m.nodes, assistantID)
+	m.cursor = len(m.path) - 1
+	m.status = "🌿 new branch created"
+	return m, nil
+}
+
+// editAndBranch opens the focused user turn in $EDITOR and, if the content
+// changed, creates a new branch off that turn's parent and re-submits it to
+// the provider, streaming the new assistant reply into view as it arrives.
+func (m tuiModel) editAndBranch() (tea.Model, tea.Cmd) {
+	if m.cursor >= len(m.path) || m.path[m.cursor].Role != "user" {
+		m.status = "⚠️ select a user turn to edit"
+		return m, nil
+	}
+	node := m.path[m.cursor]
+	edited, err := openInEditor(node.Content)
+	if err != nil {
+		m.status = fmt.Sprintf("❌ edit failed: %v", err)
+		return m, nil
+	}
+	edited = strings.TrimSpace(edited)
+	if edited == "" || edited == node.Content {
+		m.status = "no changes, branch not created"
+		return m, nil
+	}
+
+	userID, err := appendNode(node.ParentID, m.tag, "user", edited)
+	if err != nil {
+		m.status = fmt.Sprintf("❌ failed to save branch: %v", err)
+		return m, nil
+	}
+	m.nodes = append(m.nodes, Node{ID: userID, ParentID: node.ParentID, Tag: m.tag, Role: "user", Content: edited})
+	m.path = leafPath(m.nodes, userID)
+	m.cursor = len(m.path) - 1
+
+	history := pathToMessages(m.path[:len(m.path)-1])
+	history = append(history, Message{Role: "user", Content: edited})
+	chunks, err := m.provider.StreamCompletion(context.Background(), history, CompletionOptions{IdleTimeout: 30 * time.Second})
+	if err != nil {
+		m.status = fmt.Sprintf("❌ failed to get response: %v", err)
+		return m, nil
+	}
+	m.streaming = true
+	m.streamBuf.Reset()
+	m.streamParent = userID
+	m.chunks = chunks
+	m.status = "🤖 streaming response…"
+	return m, waitForChunk(chunks)
+}
+
+// pathToMessages maps a branch path (oldest first) onto the provider
+// message list, in the order the turns actually happened.
+func pathToMessages(path []Node) []Message {
+	messages := make([]Message, 0, len(path))
+	for _, n := range path {
+		messages = append(messages, Message{Role: n.Role, Content: n.Content})
+	}
+	return messages
+}
+
+// cycleSibling moves the focused node to the next sibling branch, if one
+// exists at that point in the tree.
+func (m *tuiModel) cycleSibling() {
+	if m.cursor >= len(m.path) {
+		return
+	}
+	node := m.path[m.cursor]
+	siblings := childrenOf(m.nodes, node.ParentID)
+	if len(siblings) < 2 {
+		m.status = "no sibling branches here"
+		return
+	}
+	for i, s := range siblings {
+		if s.ID == node.ID {
+			next := siblings[(i+1)%len(siblings)]
+			tail := leafPath(m.nodes, tagTip(m.nodes, m.tag))
+			// Prefer a leaf under the sibling if one exists further down its
+			// own branch; otherwise the sibling itself is the tip.
+			leaf := next
+			for _, t := range tail {
+				if t.ID == next.ID {
+					leaf = t
+				}
+			}
+			rest := leafPath(m.nodes, leaf.ID)
+			m.path = append(m.path[:m.cursor], rest...)
+			return
+		}
+	}
+}
+
+func (m tuiModel) View() string {
+	if m.tagSelect {
+		return m.viewTagSelect()
+	}
+	var b strings.Builder
+	for i, n := range m.path {
+		marker := "  "
+		if i == m.cursor {
+			marker = "▶ "
+		}
+		role := "🧑"
+		if n.Role == "assistant" {
+			role = "🤖"
+		}
+		content := n.Content
+		if m.renderer != nil {
+			if rendered, err := m.renderer.Render(content); err == nil {
+				content = rendered
+			}
+		}
+		fmt.Fprintf(&b, "%s%s %s\n", marker, role, strings.TrimSpace(content))
+	}
+	if m.streaming {
+		content := m.streamBuf.String()
+		if m.renderer != nil {
+			if rendered, err := m.renderer.Render(content); err == nil {
+				content = rendered
+			}
+		}
+		fmt.Fprintf(&b, "  🤖 %s▌\n", strings.TrimSpace(content))
+	}
+	b.WriteString("\n")
+	if m.status != "" {
+		b.WriteString(m.status + "\n")
+	}
+	b.WriteString("[j/k] move  [tab] sibling branch  [e] edit & branch  [t] switch tag  [q] quit\n")
+	return b.String()
+}
+
+// viewTagSelect renders the tag switcher list opened by the "t" key.
+func (m tuiModel) viewTagSelect() string {
+	var b strings.Builder
+	b.WriteString("Switch to tag:\n\n")
+	for i, t := range m.tags {
+		marker := "  "
+		if i == m.tagCursor {
+			marker = "▶ "
+		}
+		current := ""
+		if t == m.tag {
+			current = " (current)"
+		}
+		fmt.Fprintf(&b, "%s%s%s\n", marker, t, current)
+	}
+	b.WriteString("\n[j/k] move  [enter] select  [esc] cancel\n")
+	return b.String()
+}
+
+// openInEditor writes content to a temp file, opens $EDITOR on it, and
+// returns the edited contents.
+func openInEditor(content string) (string, error) {
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+	tmp, err := os.CreateTemp("", "herochat-*.md")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write temp file: %v", err)
+	}
+	tmp.Close()
+
+	cmd := exec.Command(editor, tmp.Name())
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor exited with error: %v", err)
+	}
+
+	data, err := os.ReadFile(tmp.Name())
+	if err != nil {
+		return "", fmt.Errorf("failed to read edited file: %v", err)
+	}
+	return string(data), nil
+}
+
+// newTUICmd builds the `herochat tui` subcommand.
+func newTUICmd() *cobra.Command {
+	var providerFlag string
+	cmd := &cobra.Command{
+		Use:   "tui <tag>",
+		Short: "Launch the interactive TUI for a conversation tag",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			tag := args[0]
+			// Load every tag's nodes, not just this one, so the in-TUI tag
+			// switcher (the "t" key) can jump to another tag without
+			// re-querying the store.
+			nodes, err := store.AllNodes(context.Background())
+			if err != nil {
+				color.Red("❌ Error loading conversations: %v", err)
+				return
+			}
+			cfg, err := loadConfig()
+			if err != nil {
+				color.Red("❌ Error loading config: %v", err)
+				return
+			}
+			provider, err := NewProvider(resolveProviderName(providerFlag, cfg), cfg)
+			if err != nil {
+				color.Red("❌ Error: %v", err)
+				return
+			}
+			p := tea.NewProgram(newTUIModel(nodes, tag, provider))
+			if _, err := p.Run(); err != nil {
+				color.Red("❌ TUI error: %v", err)
+			}
+		},
+	}
+	cmd.Flags().StringVar(&providerFlag, "provider", "", "backend to use (heroku, openai, anthropic, ollama, gemini); defaults to $HEROCHAT_PROVIDER or the config file")
+	return cmd
+}